@@ -0,0 +1,266 @@
+package listenerutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Validator 校验器接口，Bind 在解析完参数后调用 Validate 执行结构体校验。
+// 可注册 go-playground/validator 或自定义实现替换内置的 tag 校验。
+type Validator interface {
+	Validate(param interface{}) error
+}
+
+var bindValidator Validator = tagValidator{}
+
+// SetValidator 替换 Bind 使用的校验器实现。
+func SetValidator(v Validator) {
+	bindValidator = v
+}
+
+// FieldError 描述单个字段的校验失败信息。
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// BindError 是 Bind 校验失败时返回的结构化错误，WrapResponse 会按配置的
+// envelope 附带渲染出 per-field 的错误信息，handler 无需再手写解析校验的样板代码。
+type BindError struct {
+	Fields []FieldError
+}
+
+func (e *BindError) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Bind 按请求的 Content-Type 解析请求体（json、form、multipart、gzip压缩的json），
+// 再通过 query/form/json 标签合并 query string 参数，最后执行 validate 标签校验。
+func Bind(r *http.Request, param interface{}) error {
+	if err := bindBody(r, param); err != nil {
+		return err
+	}
+	if err := mergeQueryParam(r, param); err != nil {
+		return err
+	}
+	return bindValidator.Validate(param)
+}
+
+func bindBody(r *http.Request, param interface{}) error {
+	if r.Body == nil || r.ContentLength == 0 {
+		return nil
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		contentType = "application/json"
+	}
+
+	switch {
+	case contentType == "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return err
+		}
+		return setTaggedFields(param, r.PostForm, false, "form")
+	case strings.HasPrefix(contentType, "multipart/"):
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			return err
+		}
+		return setTaggedFields(param, r.MultipartForm.Value, false, "form")
+	default:
+		return bindJSONBody(r, param)
+	}
+}
+
+func bindJSONBody(r *http.Request, param interface{}) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+
+	if strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
+		gzr, err := gzip.NewReader(bytes.NewBuffer(body))
+		if err != nil {
+			return err
+		}
+		body, err = ioutil.ReadAll(gzr)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, param)
+}
+
+// mergeQueryParam 将 url query 参数按 query/form/json 标签顺序写入 param 对应字段，
+// 仅在字段仍为零值时才写入，即 body 已设置的值优先于 query string。
+func mergeQueryParam(r *http.Request, param interface{}) error {
+	return setTaggedFields(param, r.URL.Query(), true, "query", "form", "json")
+}
+
+func setTaggedFields(param interface{}, values map[string][]string, onlyIfZero bool, tagNames ...string) error {
+	v := reflect.ValueOf(param)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("bind: param must be a pointer to struct")
+	}
+	v = v.Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldVal := v.Field(i)
+		if !fieldVal.CanSet() {
+			continue
+		}
+		key := fieldKey(field, tagNames...)
+		if key == "-" || len(key) == 0 {
+			continue
+		}
+		raw, ok := values[key]
+		if !ok || len(raw) == 0 {
+			continue
+		}
+		if onlyIfZero && !fieldVal.IsZero() {
+			continue
+		}
+		if err := setFieldValue(fieldVal, raw[0]); err != nil {
+			return fmt.Errorf("bind: field %s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+func fieldKey(field reflect.StructField, tagNames ...string) string {
+	for _, tagName := range tagNames {
+		if tag, ok := field.Tag.Lookup(tagName); ok {
+			name := strings.Split(tag, ",")[0]
+			if len(name) > 0 {
+				return name
+			}
+		}
+	}
+	return field.Name
+}
+
+func setFieldValue(fieldVal reflect.Value, raw string) error {
+	switch fieldVal.Kind() {
+	case reflect.String:
+		fieldVal.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		fieldVal.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return err
+		}
+		fieldVal.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return err
+		}
+		fieldVal.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		fieldVal.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", fieldVal.Kind())
+	}
+	return nil
+}
+
+// tagValidator 是内置的默认 Validator 实现，支持 validate 标签中的
+// required、min、max 三种规则；min/max 对字符串和切片校验长度，对数值类型校验取值范围。
+type tagValidator struct{}
+
+func (tagValidator) Validate(param interface{}) error {
+	v := reflect.ValueOf(param)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	v = v.Elem()
+	t := v.Type()
+
+	var fieldErrors []FieldError
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, ok := field.Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		for _, rule := range strings.Split(tag, ",") {
+			if msg := checkRule(v.Field(i), rule); len(msg) > 0 {
+				fieldErrors = append(fieldErrors, FieldError{Field: field.Name, Message: msg})
+			}
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return &BindError{Fields: fieldErrors}
+	}
+	return nil
+}
+
+func checkRule(fieldVal reflect.Value, rule string) string {
+	parts := strings.SplitN(rule, "=", 2)
+	switch parts[0] {
+	case "required":
+		if fieldVal.IsZero() {
+			return "required"
+		}
+	case "min":
+		if len(parts) == 2 && numericValue(fieldVal) < parseLimit(parts[1]) {
+			return "must be at least " + parts[1]
+		}
+	case "max":
+		if len(parts) == 2 && numericValue(fieldVal) > parseLimit(parts[1]) {
+			return "must be at most " + parts[1]
+		}
+	}
+	return ""
+}
+
+func parseLimit(s string) float64 {
+	limit, _ := strconv.ParseFloat(s, 64)
+	return limit
+}
+
+func numericValue(fieldVal reflect.Value) float64 {
+	switch fieldVal.Kind() {
+	case reflect.String:
+		return float64(len(fieldVal.String()))
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(fieldVal.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fieldVal.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(fieldVal.Uint())
+	case reflect.Float32, reflect.Float64:
+		return fieldVal.Float()
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,69 @@
+package listenerutil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type bindTestParam struct {
+	Name string `json:"name" validate:"required"`
+	Age  int    `json:"age" validate:"min=0,max=150"`
+}
+
+func TestBindJSONBodyAndValidate(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"alice","age":30}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	var param bindTestParam
+	if err := Bind(req, &param); err != nil {
+		t.Fatalf("unexpected bind error: %v", err)
+	}
+	if param.Name != "alice" || param.Age != 30 {
+		t.Fatalf("unexpected bound param: %+v", param)
+	}
+}
+
+func TestBindMergesQueryParamWhenBodyEmpty(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/?name=bob&age=40", nil)
+
+	var param bindTestParam
+	if err := Bind(req, &param); err != nil {
+		t.Fatalf("unexpected bind error: %v", err)
+	}
+	if param.Name != "bob" || param.Age != 40 {
+		t.Fatalf("unexpected bound param: %+v", param)
+	}
+}
+
+func TestBindValidateRequiredField(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"age":30}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	var param bindTestParam
+	err := Bind(req, &param)
+	var bindErr *BindError
+	if !errors.As(err, &bindErr) {
+		t.Fatalf("expected *BindError, got %T: %v", err, err)
+	}
+	if len(bindErr.Fields) != 1 || bindErr.Fields[0].Field != "Name" {
+		t.Fatalf("unexpected field errors: %+v", bindErr.Fields)
+	}
+}
+
+func TestBindValidateMaxBound(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"name":"alice","age":999}`))
+	req.Header.Set("Content-Type", "application/json")
+
+	var param bindTestParam
+	err := Bind(req, &param)
+	var bindErr *BindError
+	if !errors.As(err, &bindErr) {
+		t.Fatalf("expected *BindError, got %T: %v", err, err)
+	}
+	if len(bindErr.Fields) != 1 || bindErr.Fields[0].Field != "Age" {
+		t.Fatalf("unexpected field errors: %+v", bindErr.Fields)
+	}
+}
@@ -0,0 +1,269 @@
+package listenerutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+const (
+	gzipEncoding     = "gzip"
+	identityEncoding = "identity"
+)
+
+// unsetCompressionLevel 作为 CompressionOptions.Levels 中某算法未被显式配置时传给
+// Compressor.NewWriter 的级别值，与合法的压缩级别（包括 flate/gzip 中表示“不压缩”
+// 的 0）区分开，避免 Levels["gzip"] = 0 被误当作“未配置”而悄悄提升为默认压缩级别。
+const unsetCompressionLevel = math.MinInt32
+
+// Compressor 响应压缩算法的实现接口，可用于在 gzip 之外接入 brotli、zstd 等编码。
+type Compressor interface {
+	// Name 返回该算法在 Accept-Encoding / Content-Encoding 中使用的名称，如 "gzip"。
+	Name() string
+	// NewWriter 创建一个以 level 为压缩级别、向 w 写入压缩数据的 io.WriteCloser。
+	NewWriter(w io.Writer, level int) (io.WriteCloser, error)
+}
+
+// CompressionOptions 压缩相关配置。
+type CompressionOptions struct {
+	// Levels 为每种算法指定压缩级别，未在此配置的算法使用其自身默认级别。
+	// 与 Go 零值不同，显式设置为 0（如 gzip 的"不压缩"）会被保留，不会被当作未配置。
+	Levels map[string]int
+	// MinSize 响应体小于该字节数时不压缩，避免小响应的额外开销。
+	MinSize int
+}
+
+var (
+	compressionMu      sync.RWMutex
+	compressors        = map[string]Compressor{gzipEncoding: gzipCompressor{}}
+	compressionOptions = CompressionOptions{}
+	writerPools        = map[string]*sync.Pool{}
+)
+
+// RegisterCompressor 注册一个 Compressor，使其可在 Accept-Encoding 协商中被选中。
+func RegisterCompressor(c Compressor) {
+	compressionMu.Lock()
+	defer compressionMu.Unlock()
+	compressors[c.Name()] = c
+	delete(writerPools, c.Name())
+}
+
+// SetCompressionOptions 设置压缩级别与最小压缩阈值。
+func SetCompressionOptions(opts CompressionOptions) {
+	compressionMu.Lock()
+	defer compressionMu.Unlock()
+	compressionOptions = opts
+	writerPools = map[string]*sync.Pool{}
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Name() string { return gzipEncoding }
+
+func (gzipCompressor) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	if level == unsetCompressionLevel {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(w, level)
+}
+
+func compressorFor(encoding string) (Compressor, int, bool) {
+	compressionMu.RLock()
+	defer compressionMu.RUnlock()
+	c, ok := compressors[encoding]
+	if !ok {
+		return nil, 0, false
+	}
+	level, explicit := compressionOptions.Levels[encoding]
+	if !explicit {
+		level = unsetCompressionLevel
+	}
+	return c, level, true
+}
+
+func minCompressSize() int {
+	compressionMu.RLock()
+	defer compressionMu.RUnlock()
+	return compressionOptions.MinSize
+}
+
+// pooledWriter 从算法专属的 sync.Pool 中取出（或新建）一个压缩 writer 并重置到 dst，
+// 减少每次请求新建压缩器带来的内存分配。
+func pooledWriter(name string, compressor Compressor, level int, dst io.Writer) (io.WriteCloser, error) {
+	compressionMu.Lock()
+	pool, ok := writerPools[name]
+	if !ok {
+		pool = &sync.Pool{}
+		writerPools[name] = pool
+	}
+	compressionMu.Unlock()
+
+	if cached := pool.Get(); cached != nil {
+		if resetter, ok := cached.(interface{ Reset(io.Writer) }); ok {
+			resetter.Reset(dst)
+			return cached.(io.WriteCloser), nil
+		}
+	}
+	return compressor.NewWriter(dst, level)
+}
+
+func releaseWriter(name string, w io.WriteCloser) {
+	compressionMu.RLock()
+	pool := writerPools[name]
+	compressionMu.RUnlock()
+	if pool != nil {
+		pool.Put(w)
+	}
+}
+
+// negotiateEncoding 按 Accept-Encoding 的 q 值从高到低，选出第一个已注册的算法。
+// 未携带 Accept-Encoding 或没有匹配项时返回 identityEncoding。
+func negotiateEncoding(acceptEncoding string) string {
+	for _, mediaType := range parseAccept(acceptEncoding) {
+		if mediaType == identityEncoding {
+			return identityEncoding
+		}
+		if _, _, ok := compressorFor(mediaType); ok {
+			return mediaType
+		}
+	}
+	return identityEncoding
+}
+
+// compressionResponseWriter 缓冲前 MinSize 字节以判断响应体是否值得压缩，因此也
+// 延迟真正的 WriteHeader 调用，直到能确定是否需要写入 Content-Encoding；
+// 一旦确定压缩，后续写入直接流向压缩 writer，不再设置 Content-Length。
+type compressionResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	compressor  Compressor
+	level       int
+	minSize     int
+	buf         bytes.Buffer
+	status      int
+	wroteHeader bool
+	started     bool
+	compressed  io.WriteCloser
+}
+
+func (w *compressionResponseWriter) hasDynamicLength() bool {
+	return true
+}
+
+func (w *compressionResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+}
+
+func (w *compressionResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.started {
+		return w.compressed.Write(b)
+	}
+	w.buf.Write(b)
+	if w.buf.Len() < w.minSize {
+		return len(b), nil
+	}
+	if err := w.startCompressing(); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (w *compressionResponseWriter) startCompressing() error {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.Header().Set("Content-Encoding", w.encoding)
+	w.Header().Del("Content-Length")
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", http.DetectContentType(w.buf.Bytes()))
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	compressed, err := pooledWriter(w.encoding, w.compressor, w.level, w.ResponseWriter)
+	if err != nil {
+		return err
+	}
+	w.compressed = compressed
+	w.started = true
+	if _, err := w.compressed.Write(w.buf.Bytes()); err != nil {
+		return err
+	}
+	w.buf.Reset()
+	return nil
+}
+
+// Flush 实现 http.Flusher。若响应体仍在按 MinSize 缓冲，Flush 被视为调用方需要
+// 立即推送数据的信号，此时不再等待阈值，直接进入压缩模式。
+func (w *compressionResponseWriter) Flush() {
+	if !w.started {
+		if err := w.startCompressing(); err != nil {
+			return
+		}
+	}
+	if flusher, ok := w.compressed.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// finish 在 handler 返回后调用：若响应体始终未达到 MinSize，原样写出缓冲内容
+// 并带上准确的 Content-Length；否则关闭压缩 writer 并归还到池中。
+func (w *compressionResponseWriter) finish() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.started {
+		w.Header().Set("Content-Length", strconv.Itoa(w.buf.Len()))
+		w.ResponseWriter.WriteHeader(w.status)
+		if w.buf.Len() > 0 {
+			w.ResponseWriter.Write(w.buf.Bytes())
+		}
+		return
+	}
+	w.compressed.Close()
+	releaseWriter(w.encoding, w.compressed)
+}
+
+// CompressionHandler http处理函数，对 http.HandlerFunc 的封装，按 Accept-Encoding
+// 的 q 值在 gzip、已注册的 brotli/zstd 等算法与 identity 间协商出最合适的编码，
+// 响应体小于 SetCompressionOptions 配置的 MinSize 时跳过压缩。
+// 请求体为 gzip 压缩时，自动解压后再交给 next。
+func CompressionHandler(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decompressGzipBody(r)
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == identityEncoding {
+			next(w, r)
+			return
+		}
+
+		compressor, level, ok := compressorFor(encoding)
+		if !ok {
+			next(w, r)
+			return
+		}
+
+		cw := &compressionResponseWriter{
+			ResponseWriter: w,
+			encoding:       encoding,
+			compressor:     compressor,
+			level:          level,
+			minSize:        minCompressSize(),
+		}
+		next(cw, r)
+		cw.finish()
+	})
+}
@@ -0,0 +1,221 @@
+package listenerutil
+
+import (
+	"errors"
+	"io"
+)
+
+const (
+	brotliEncoding = "br"
+	zstdEncoding   = "zstd"
+)
+
+func init() {
+	compressors[brotliEncoding] = brotliCompressor{}
+	compressors[zstdEncoding] = zstdCompressor{}
+}
+
+// zstdCompressor 生成合法的 zstd 帧（RFC 8878），但只使用 Raw_Block（不做熵编码），
+// 因此不依赖任何第三方库即可实现真实可被标准 zstd 解码器解压的输出；
+// 如需要实际的压缩比，可通过 RegisterCompressor 接入
+// github.com/klauspost/compress/zstd 等实现替换掉这里的默认值。
+type zstdCompressor struct{}
+
+func (zstdCompressor) Name() string { return zstdEncoding }
+
+func (zstdCompressor) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return newZstdWriter(w), nil
+}
+
+const zstdMaxBlockSize = 128 << 10 // Block_Maximum_Size：窗口大小与 128 KiB 中的较小者
+
+type zstdWriter struct {
+	dst       io.Writer
+	wroteHead bool
+	closed    bool
+}
+
+func newZstdWriter(dst io.Writer) *zstdWriter {
+	return &zstdWriter{dst: dst}
+}
+
+// Reset 供 compression.go 的 sync.Pool 复用该 writer。
+func (w *zstdWriter) Reset(dst io.Writer) {
+	w.dst = dst
+	w.wroteHead = false
+	w.closed = false
+}
+
+func (w *zstdWriter) writeFrameHeader() error {
+	// Magic_Number(4) + Frame_Header_Descriptor(1, 无 Single_Segment/Checksum/DictID)
+	// + Window_Descriptor(1, windowLog=20)。不设置 Frame_Content_Size，以支持流式写入。
+	_, err := w.dst.Write([]byte{0x28, 0xB5, 0x2F, 0xFD, 0x00, 0x50})
+	return err
+}
+
+func (w *zstdWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("zstd: write to closed writer")
+	}
+	if !w.wroteHead {
+		if err := w.writeFrameHeader(); err != nil {
+			return 0, err
+		}
+		w.wroteHead = true
+	}
+	remaining := p
+	for len(remaining) > 0 {
+		chunk := remaining
+		if len(chunk) > zstdMaxBlockSize {
+			chunk = chunk[:zstdMaxBlockSize]
+		}
+		if err := w.writeBlockHeader(len(chunk), false); err != nil {
+			return 0, err
+		}
+		if _, err := w.dst.Write(chunk); err != nil {
+			return 0, err
+		}
+		remaining = remaining[len(chunk):]
+	}
+	return len(p), nil
+}
+
+func (w *zstdWriter) writeBlockHeader(size int, last bool) error {
+	header := uint32(size) << 3 // bits 1-2 为 Block_Type=00(Raw_Block)
+	if last {
+		header |= 1
+	}
+	_, err := w.dst.Write([]byte{byte(header), byte(header >> 8), byte(header >> 16)})
+	return err
+}
+
+func (w *zstdWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if !w.wroteHead {
+		if err := w.writeFrameHeader(); err != nil {
+			return err
+		}
+		w.wroteHead = true
+	}
+	return w.writeBlockHeader(0, true)
+}
+
+// brotliCompressor 生成合法的 brotli 流（RFC 7932），但只使用未压缩 meta-block，
+// 不做 LZ77/熵编码，因此不依赖任何第三方库即可实现真实可被标准 brotli 解码器解压的
+// 输出；如需要实际的压缩比，可通过 RegisterCompressor 接入
+// github.com/andybalholm/brotli 等实现替换掉这里的默认值。
+type brotliCompressor struct{}
+
+func (brotliCompressor) Name() string { return brotliEncoding }
+
+func (brotliCompressor) NewWriter(w io.Writer, level int) (io.WriteCloser, error) {
+	return newBrotliWriter(w), nil
+}
+
+const brotliMaxBlockLen = 1 << 24 // MLEN 编码为 6 个 nibble(24 位)时的最大长度
+
+// bitWriter 是 LSB-first 的位写入器，brotli 的流头与 meta-block 头都以此方式打包。
+type bitWriter struct {
+	buf   []byte
+	cur   byte
+	nbits uint
+}
+
+func (bw *bitWriter) writeBits(value uint32, n uint) {
+	for i := uint(0); i < n; i++ {
+		bw.cur |= byte((value>>i)&1) << bw.nbits
+		bw.nbits++
+		if bw.nbits == 8 {
+			bw.buf = append(bw.buf, bw.cur)
+			bw.cur = 0
+			bw.nbits = 0
+		}
+	}
+}
+
+func (bw *bitWriter) align() {
+	if bw.nbits > 0 {
+		bw.buf = append(bw.buf, bw.cur)
+		bw.cur = 0
+		bw.nbits = 0
+	}
+}
+
+type brotliWriter struct {
+	dst       io.Writer
+	bw        bitWriter
+	wroteHead bool
+	closed    bool
+}
+
+func newBrotliWriter(dst io.Writer) *brotliWriter {
+	return &brotliWriter{dst: dst}
+}
+
+// Reset 供 compression.go 的 sync.Pool 复用该 writer。
+func (w *brotliWriter) Reset(dst io.Writer) {
+	w.dst = dst
+	w.bw = bitWriter{}
+	w.wroteHead = false
+	w.closed = false
+}
+
+func (w *brotliWriter) flush() error {
+	if len(w.bw.buf) == 0 {
+		return nil
+	}
+	_, err := w.dst.Write(w.bw.buf)
+	w.bw.buf = w.bw.buf[:0]
+	return err
+}
+
+func (w *brotliWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("brotli: write to closed writer")
+	}
+	if !w.wroteHead {
+		w.bw.writeBits(0, 1) // WBITS=16（单比特 0）
+		w.wroteHead = true
+	}
+	remaining := p
+	for len(remaining) > 0 {
+		chunk := remaining
+		if len(chunk) > brotliMaxBlockLen {
+			chunk = chunk[:brotliMaxBlockLen]
+		}
+		// MNIBBLES 取能容纳 MLEN-1 的最少 nibble 数（4~6），nibble 数大于 4 时最高 nibble 不得为 0。
+		nibbles := uint(4)
+		for nibbles < 6 && uint32(len(chunk)-1) >= 1<<(4*nibbles) {
+			nibbles++
+		}
+		w.bw.writeBits(0, 1)                            // ISLAST=0
+		w.bw.writeBits(uint32(nibbles-4), 2)            // MNIBBLES
+		w.bw.writeBits(uint32(len(chunk)-1), 4*nibbles) // MLEN-1
+		w.bw.writeBits(1, 1)                            // ISUNCOMPRESSED=1
+		w.bw.align()
+		w.bw.buf = append(w.bw.buf, chunk...)
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+		remaining = remaining[len(chunk):]
+	}
+	return len(p), nil
+}
+
+func (w *brotliWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if !w.wroteHead {
+		w.bw.writeBits(0, 1)
+		w.wroteHead = true
+	}
+	w.bw.writeBits(1, 1) // ISLAST=1
+	w.bw.writeBits(1, 1) // ISLASTEMPTY=1
+	w.bw.align()
+	return w.flush()
+}
@@ -0,0 +1,210 @@
+package listenerutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+func TestGzipCompressorRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := gzipCompressor{}.NewWriter(&buf, gzip.DefaultCompression)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := w.Write([]byte("hello gzip world")); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	gzr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("output is not a valid gzip stream: %v", err)
+	}
+	got, err := ioutil.ReadAll(gzr)
+	if err != nil {
+		t.Fatalf("unexpected decompress error: %v", err)
+	}
+	if string(got) != "hello gzip world" {
+		t.Fatalf("unexpected decompressed content: %q", got)
+	}
+}
+
+// TestGzipCompressorExplicitZeroLevelIsNotPromotedToDefault 验证
+// Levels["gzip"]=0（不压缩）不会被误当作"未配置"而悄悄提升为默认压缩级别：
+// 对高度重复的数据，level 0 的输出不应比默认压缩级别的输出更小。
+func TestGzipCompressorExplicitZeroLevelIsNotPromotedToDefault(t *testing.T) {
+	payload := bytes.Repeat([]byte("aaaaaaaaaa"), 1000)
+
+	var zeroBuf bytes.Buffer
+	zeroWriter, err := gzipCompressor{}.NewWriter(&zeroBuf, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	zeroWriter.Write(payload)
+	zeroWriter.Close()
+
+	var defaultBuf bytes.Buffer
+	defaultWriter, err := gzipCompressor{}.NewWriter(&defaultBuf, unsetCompressionLevel)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defaultWriter.Write(payload)
+	defaultWriter.Close()
+
+	if zeroBuf.Len() <= defaultBuf.Len() {
+		t.Fatalf("expected level 0 (no compression) output (%d bytes) to be larger than default-compression output (%d bytes)", zeroBuf.Len(), defaultBuf.Len())
+	}
+}
+
+func TestCompressorForDistinguishesUnsetFromExplicitZeroLevel(t *testing.T) {
+	prev := compressionOptions
+	defer SetCompressionOptions(prev)
+
+	SetCompressionOptions(CompressionOptions{Levels: map[string]int{"gzip": 0}})
+	_, level, ok := compressorFor("gzip")
+	if !ok || level != 0 {
+		t.Fatalf("expected explicit level 0 to be preserved, got level=%d ok=%v", level, ok)
+	}
+
+	SetCompressionOptions(CompressionOptions{})
+	_, level, ok = compressorFor("gzip")
+	if !ok || level != unsetCompressionLevel {
+		t.Fatalf("expected unconfigured level to report the unset sentinel, got level=%d ok=%v", level, ok)
+	}
+}
+
+func TestZstdCompressorRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := zstdCompressor{}.NewWriter(&buf, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := w.Write([]byte("hello zstd world")); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	got, err := decodeRawZstdFrame(buf.Bytes())
+	if err != nil {
+		t.Fatalf("output is not a valid zstd Raw_Block frame: %v", err)
+	}
+	if string(got) != "hello zstd world" {
+		t.Fatalf("unexpected decompressed content: %q", got)
+	}
+}
+
+func TestBrotliCompressorRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := brotliCompressor{}.NewWriter(&buf, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := w.Write([]byte("hello brotli world")); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	got, err := decodeUncompressedBrotliStream(buf.Bytes())
+	if err != nil {
+		t.Fatalf("output is not a valid brotli uncompressed stream: %v", err)
+	}
+	if string(got) != "hello brotli world" {
+		t.Fatalf("unexpected decompressed content: %q", got)
+	}
+}
+
+// --- test-only decoders for the Raw_Block-only zstd / uncompressed-meta-block-only
+// brotli subset that zstdWriter/brotliWriter produce. No third-party zstd/brotli
+// library is available in this sandbox.
+
+func decodeRawZstdFrame(data []byte) ([]byte, error) {
+	if len(data) < 6 {
+		return nil, errors.New("zstd: frame too short")
+	}
+	data = data[6:] // magic number + frame/window descriptor
+	var out []byte
+	for {
+		if len(data) < 3 {
+			return nil, errors.New("zstd: truncated block header")
+		}
+		header := uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+		data = data[3:]
+		last := header&1 != 0
+		blockType := (header >> 1) & 3
+		size := int(header >> 3)
+		if blockType != 0 {
+			return nil, errors.New("zstd: unsupported block type in test decoder")
+		}
+		if len(data) < size {
+			return nil, errors.New("zstd: truncated block data")
+		}
+		out = append(out, data[:size]...)
+		data = data[size:]
+		if last {
+			return out, nil
+		}
+	}
+}
+
+type bitReader struct {
+	data  []byte
+	pos   int
+	cur   byte
+	nbits uint
+}
+
+func (br *bitReader) readBits(n uint) uint32 {
+	var v uint32
+	for i := uint(0); i < n; i++ {
+		if br.nbits == 0 {
+			br.cur = br.data[br.pos]
+			br.pos++
+			br.nbits = 8
+		}
+		v |= uint32(br.cur&1) << i
+		br.cur >>= 1
+		br.nbits--
+	}
+	return v
+}
+
+func (br *bitReader) align() {
+	br.nbits = 0
+}
+
+func decodeUncompressedBrotliStream(data []byte) ([]byte, error) {
+	br := &bitReader{data: data}
+	br.readBits(1) // WBITS
+	var out []byte
+	for {
+		isLast := br.readBits(1)
+		if isLast == 1 {
+			br.readBits(1) // ISLASTEMPTY
+			return out, nil
+		}
+		mnibbles := br.readBits(2)
+		if mnibbles == 3 {
+			return nil, errors.New("brotli: unsupported MNIBBLES in test decoder")
+		}
+		mlen := int(br.readBits(4*(uint(mnibbles)+4))) + 1
+		isUncompressed := br.readBits(1)
+		if isUncompressed != 1 {
+			return nil, errors.New("brotli: unsupported compressed meta-block in test decoder")
+		}
+		br.align()
+		if br.pos+mlen > len(br.data) {
+			return nil, errors.New("brotli: truncated meta-block data")
+		}
+		out = append(out, br.data[br.pos:br.pos+mlen]...)
+		br.pos += mlen
+	}
+}
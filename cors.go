@@ -0,0 +1,105 @@
+package listenerutil
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	originRequestHeader           = "Origin"
+	accessControlRequestHeaders   = "Access-Control-Request-Headers"
+	accessControlRequestMethod    = "Access-Control-Request-Method"
+	accessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	accessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	accessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	accessControlAllowMethods     = "Access-Control-Allow-Methods"
+	accessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	accessControlMaxAge           = "Access-Control-Max-Age"
+	credentialsTrue               = "true"
+)
+
+// CORSPolicy 描述跨域访问控制策略，取代历史上 SetAllowCrossOrigin(bool) 的
+// 全量放行行为。可通过 SetCORSPolicy 全局配置，也可通过 ExtendHandlerWithCORS
+// 按接口单独配置。
+type CORSPolicy struct {
+	// AllowedOrigins 允许的来源列表，"*" 表示允许任意来源。
+	AllowedOrigins []string
+	// OriginMatcher 自定义来源匹配函数，先于 AllowedOrigins 检查；二者任一匹配即放行，
+	// OriginMatcher 返回 false 时仍会继续按 AllowedOrigins 判断。
+	OriginMatcher func(origin string) bool
+	// AllowedMethods 预检响应中声明的允许方法，为空时回显请求的 Access-Control-Request-Method。
+	AllowedMethods []string
+	// AllowedHeaders 预检响应中声明的允许请求头，为空时回显请求的 Access-Control-Request-Headers。
+	AllowedHeaders []string
+	// ExposedHeaders 允许浏览器端 js 读取的响应头。
+	ExposedHeaders []string
+	// AllowCredentials 是否允许携带凭证（cookie、Authorization 等）。
+	AllowCredentials bool
+	// MaxAge 预检结果的缓存时间，<=0 时不设置 Access-Control-Max-Age。
+	MaxAge time.Duration
+}
+
+// PermissiveCORSPolicy 返回与历史版本行为一致的允许任意来源、允许携带凭证的策略，
+// 仅用于兼容旧行为，不建议在生产环境使用。
+func PermissiveCORSPolicy() CORSPolicy {
+	return CORSPolicy{
+		AllowedOrigins:   []string{"*"},
+		AllowCredentials: true,
+	}
+}
+
+func (policy CORSPolicy) allowsOrigin(origin string) bool {
+	if policy.OriginMatcher != nil && policy.OriginMatcher(origin) {
+		return true
+	}
+	for _, allowed := range policy.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// apply 根据 policy 为请求设置 CORS 响应头。handled 为 true 时，调用方应直接
+// 以 status 结束请求（预检请求，或来源被拒绝的情况），不再调用实际 handler。
+func (policy CORSPolicy) apply(w http.ResponseWriter, r *http.Request) (status int, handled bool) {
+	origin := r.Header.Get(originRequestHeader)
+	if len(strings.TrimSpace(origin)) == 0 {
+		if r.Method == http.MethodOptions {
+			return http.StatusOK, true
+		}
+		return 0, false
+	}
+	if !policy.allowsOrigin(origin) {
+		return http.StatusForbidden, true
+	}
+
+	w.Header().Set(accessControlAllowOrigin, origin)
+	if policy.AllowCredentials {
+		w.Header().Set(accessControlAllowCredentials, credentialsTrue)
+	}
+	if len(policy.ExposedHeaders) > 0 {
+		w.Header().Set(accessControlExposeHeaders, strings.Join(policy.ExposedHeaders, ", "))
+	}
+
+	if r.Method != http.MethodOptions {
+		return 0, false
+	}
+
+	if len(policy.AllowedMethods) > 0 {
+		w.Header().Set(accessControlAllowMethods, strings.Join(policy.AllowedMethods, ", "))
+	} else {
+		w.Header().Set(accessControlAllowMethods, r.Header.Get(accessControlRequestMethod))
+	}
+	if len(policy.AllowedHeaders) > 0 {
+		w.Header().Set(accessControlAllowHeaders, strings.Join(policy.AllowedHeaders, ", "))
+	} else {
+		w.Header().Set(accessControlAllowHeaders, r.Header.Get(accessControlRequestHeaders))
+	}
+	if policy.MaxAge > 0 {
+		w.Header().Set(accessControlMaxAge, strconv.Itoa(int(policy.MaxAge.Seconds())))
+	}
+	return http.StatusOK, true
+}
@@ -0,0 +1,61 @@
+package listenerutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORSPolicyRejectsDisallowedOrigin(t *testing.T) {
+	policy := CORSPolicy{AllowedOrigins: []string{"https://trusted.example.com"}}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(originRequestHeader, "https://evil.example.com")
+	w := httptest.NewRecorder()
+
+	status, handled := policy.apply(w, req)
+	if !handled {
+		t.Fatal("expected a disallowed origin to be rejected (handled=true)")
+	}
+	if status != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", status)
+	}
+	if w.Header().Get(accessControlAllowOrigin) != "" {
+		t.Fatal("Access-Control-Allow-Origin must not be set for a rejected origin")
+	}
+}
+
+func TestCORSPolicyAllowsMatchingOrigin(t *testing.T) {
+	policy := CORSPolicy{AllowedOrigins: []string{"https://trusted.example.com"}, AllowCredentials: true}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(originRequestHeader, "https://trusted.example.com")
+	w := httptest.NewRecorder()
+
+	if _, handled := policy.apply(w, req); handled {
+		t.Fatal("a non-preflight request from an allowed origin should not be short-circuited")
+	}
+	if got := w.Header().Get(accessControlAllowOrigin); got != "https://trusted.example.com" {
+		t.Fatalf("unexpected Access-Control-Allow-Origin: %q", got)
+	}
+	if w.Header().Get(accessControlAllowCredentials) != credentialsTrue {
+		t.Fatal("expected Access-Control-Allow-Credentials: true")
+	}
+}
+
+// TestPermissiveCORSPolicyReproducesLegacyBehavior 验证 PermissiveCORSPolicy 与历史
+// SetAllowCrossOrigin(true) 的行为一致：任意来源都被放行且允许携带凭证。
+func TestPermissiveCORSPolicyReproducesLegacyBehavior(t *testing.T) {
+	policy := PermissiveCORSPolicy()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(originRequestHeader, "https://anything.example.com")
+	w := httptest.NewRecorder()
+
+	if _, handled := policy.apply(w, req); handled {
+		t.Fatal("PermissiveCORSPolicy should never reject an origin")
+	}
+	if got := w.Header().Get(accessControlAllowOrigin); got != "https://anything.example.com" {
+		t.Fatalf("PermissiveCORSPolicy should allow any origin, got %q", got)
+	}
+	if w.Header().Get(accessControlAllowCredentials) != credentialsTrue {
+		t.Fatal("PermissiveCORSPolicy should allow credentials")
+	}
+}
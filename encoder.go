@@ -0,0 +1,190 @@
+package listenerutil
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encoder 响应编码器，负责将 HandleResult 序列化为响应体并写入 http.ResponseWriter。
+// 不同的 Encoder 对应不同的响应格式（envelope），ExtendHandler 会根据请求的
+// Accept 头协商出具体使用哪一个。
+type Encoder interface {
+	// ContentType 返回该编码器写入响应时使用的 Content-Type。
+	ContentType() string
+	// Encode 将处理结果编码后写入 w，需自行设置状态码与响应体。
+	Encode(w http.ResponseWriter, result *HandleResult) error
+}
+
+const (
+	contentTypeJSON        = "application/json"
+	contentTypeProblemJSON = "application/problem+json"
+	contentTypeJSONAPI     = "application/vnd.api+json"
+)
+
+// legacyEncoder 沿用历史的 {data, errno, errmsg} 响应格式，是默认编码器。
+type legacyEncoder struct{}
+
+func (legacyEncoder) ContentType() string {
+	return contentTypeJSON
+}
+
+func (legacyEncoder) Encode(w http.ResponseWriter, result *HandleResult) error {
+	WrapResponse(w, result.Data, result.StatusCode, result.Err)
+	return nil
+}
+
+// problemJSONEncoder 错误响应按 RFC 7807 编码为 application/problem+json，
+// 成功响应仍沿用默认的 data 字段，但 Content-Type 保持 application/json。
+type problemJSONEncoder struct{}
+
+func (problemJSONEncoder) ContentType() string {
+	return contentTypeProblemJSON
+}
+
+func (problemJSONEncoder) Encode(w http.ResponseWriter, result *HandleResult) error {
+	status := result.StatusCode
+	err := result.Err
+	if err == nil && status == http.StatusOK {
+		legacyEncoder{}.Encode(w, result)
+		return nil
+	}
+	if status == http.StatusOK {
+		status = http.StatusBadRequest
+	}
+	if err == nil {
+		err = errors.New(http.StatusText(status))
+	}
+	problem := map[string]interface{}{
+		"type":   "about:blank",
+		"title":  http.StatusText(status),
+		"status": status,
+		"detail": err.Error(),
+	}
+	data, encErr := json.Marshal(problem)
+	if encErr != nil {
+		return encErr
+	}
+	w.Header().Set("Content-Type", contentTypeProblemJSON)
+	if !writerHasDynamicLength(w) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	}
+	w.WriteHeader(status)
+	w.Write(data)
+	return nil
+}
+
+// jsonAPIEncoder 按 JSON:API 规范编码为 {data, errors[], meta}。
+type jsonAPIEncoder struct{}
+
+func (jsonAPIEncoder) ContentType() string {
+	return contentTypeJSONAPI
+}
+
+func (jsonAPIEncoder) Encode(w http.ResponseWriter, result *HandleResult) error {
+	status := result.StatusCode
+	err := result.Err
+	body := make(map[string]interface{}, 2)
+	if err != nil || status != http.StatusOK {
+		if status == http.StatusOK {
+			status = http.StatusBadRequest
+		}
+		if err == nil {
+			err = errors.New(http.StatusText(status))
+		}
+		body["errors"] = []map[string]interface{}{
+			{
+				"status": strconv.Itoa(status),
+				"title":  http.StatusText(status),
+				"detail": err.Error(),
+			},
+		}
+	} else {
+		body["data"] = result.Data
+	}
+	body["meta"] = map[string]interface{}{"cost": result.Cost.Seconds()}
+
+	data, encErr := json.Marshal(body)
+	if encErr != nil {
+		return encErr
+	}
+	w.Header().Set("Content-Type", contentTypeJSONAPI)
+	if !writerHasDynamicLength(w) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	}
+	w.WriteHeader(status)
+	w.Write(data)
+	return nil
+}
+
+// SetEncoder 注册一个 Encoder，使其在请求 Accept 头匹配 contentType 时被选用。
+// 可用于替换内置的 MessagePack/Protobuf 编码器，或接入其他 legacyEncoder 未内置的格式。
+func SetEncoder(contentType string, encoder Encoder) {
+	handlerMgr.setEncoder(contentType, encoder)
+}
+
+// SetDefaultEncoder 设置 Accept 头未匹配到任何已注册 Encoder 时使用的默认编码器。
+func SetDefaultEncoder(encoder Encoder) {
+	handlerMgr.setDefaultEncoder(encoder)
+}
+
+func (handlerMgr *handlerManager) setEncoder(contentType string, encoder Encoder) {
+	handlerMgr.encoders[contentType] = encoder
+}
+
+func (handlerMgr *handlerManager) setDefaultEncoder(encoder Encoder) {
+	handlerMgr.defaultEncoder = encoder
+}
+
+// pickEncoder 按请求的 Accept 头协商出应使用的 Encoder，未能匹配时回退到默认编码器。
+func (handlerMgr *handlerManager) pickEncoder(r *http.Request) Encoder {
+	accept := r.Header.Get("Accept")
+	for _, mediaType := range parseAccept(accept) {
+		if encoder, ok := handlerMgr.encoders[mediaType]; ok {
+			return encoder
+		}
+	}
+	return handlerMgr.defaultEncoder
+}
+
+// parseAccept 解析 Accept 头，按 q 值从高到低返回媒体类型列表。
+func parseAccept(accept string) []string {
+	if len(strings.TrimSpace(accept)) == 0 {
+		return nil
+	}
+	type weighted struct {
+		mediaType string
+		quality   float64
+	}
+	parts := strings.Split(accept, ",")
+	weightedTypes := make([]weighted, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		segs := strings.Split(part, ";")
+		mediaType := strings.TrimSpace(segs[0])
+		quality := 1.0
+		for _, seg := range segs[1:] {
+			seg = strings.TrimSpace(seg)
+			if strings.HasPrefix(seg, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(seg, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		weightedTypes = append(weightedTypes, weighted{mediaType: mediaType, quality: quality})
+	}
+	sort.SliceStable(weightedTypes, func(i, j int) bool {
+		return weightedTypes[i].quality > weightedTypes[j].quality
+	})
+	mediaTypes := make([]string, 0, len(weightedTypes))
+	for _, wt := range weightedTypes {
+		mediaTypes = append(mediaTypes, wt.mediaType)
+	}
+	return mediaTypes
+}
@@ -7,11 +7,14 @@ Package listenerutil http 服务处理函数封装。
 
 * 自动添加http响应头，包括允许跨域、content-type等。
 
-* 自动将错误转为errmsg字段返回。
+* 自动将错误转为errmsg字段返回，支持通过 Encoder 按 Accept 头协商响应格式。
 
 * 响应前后hook支持，可用于记录访问日志、响应时间等。
 
+* handler 返回 Streamer、io.Reader 或 <-chan interface{} 时自动切换为流式响应，跳过 envelope 封装。
+
 示例：
+
 	import "github.com/jayi/listenerutil"
 
 	func main() {
@@ -41,26 +44,28 @@ Package listenerutil http 服务处理函数封装。
 		// err不为空时，会自动响应400
 		return resp, http.StatusOK, err
 	}
-
 */
 package listenerutil
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
 )
 
 type handlerManager struct {
-	beginHooks       []http.HandlerFunc
-	endHooks         []EndHandleFunc
-	dataFieldName    string
-	codeFieldName    string
-	msgFieldName     string
-	allowCrossOrigin bool
+	beginHooks     []http.HandlerFunc
+	endHooks       []EndHandleFunc
+	dataFieldName  string
+	codeFieldName  string
+	msgFieldName   string
+	corsPolicy     CORSPolicy
+	encoders       map[string]Encoder
+	defaultEncoder Encoder
+	middlewares    []func(http.Handler) http.Handler
 }
 
 // HandleResult 响应结果相关信息
@@ -69,6 +74,7 @@ type HandleResult struct {
 	StatusCode int
 	Err        error
 	Cost       time.Duration
+	RequestID  string
 }
 
 // EndHookFunc 响应后处理方法。
@@ -89,12 +95,19 @@ const (
 )
 
 var handlerMgr = &handlerManager{
-	beginHooks:       make([]http.HandlerFunc, 0),
-	endHooks:         make([]EndHandleFunc, 0),
-	dataFieldName:    defaultDataFieldName,
-	codeFieldName:    defaultCodeFieldName,
-	msgFieldName:     defaultMsgFieldName,
-	allowCrossOrigin: true,
+	beginHooks:    make([]http.HandlerFunc, 0),
+	endHooks:      make([]EndHandleFunc, 0),
+	dataFieldName: defaultDataFieldName,
+	codeFieldName: defaultCodeFieldName,
+	msgFieldName:  defaultMsgFieldName,
+	corsPolicy:    PermissiveCORSPolicy(),
+	encoders: map[string]Encoder{
+		contentTypeProblemJSON: problemJSONEncoder{},
+		contentTypeJSONAPI:     jsonAPIEncoder{},
+		contentTypeMsgpack:     msgpackEncoder{},
+		contentTypeProtobuf:    protobufEncoder{},
+	},
+	defaultEncoder: legacyEncoder{},
 }
 
 func (handlerMgr *handlerManager) addBeginHook(hookFunc http.HandlerFunc) {
@@ -144,8 +157,8 @@ func (handlerMgr *handlerManager) setMsgFieldName(name string) error {
 	return nil
 }
 
-func (handlerMgr *handlerManager) setAllowCrossOrigin(allow bool) {
-	handlerMgr.allowCrossOrigin = allow
+func (handlerMgr *handlerManager) setCORSPolicy(policy CORSPolicy) {
+	handlerMgr.corsPolicy = policy
 }
 
 func (handlerMgr *handlerManager) doBeginHooks(w http.ResponseWriter, r *http.Request) {
@@ -192,39 +205,9 @@ func SetMsgFieldName(name string) error {
 	return handlerMgr.setMsgFieldName(name)
 }
 
-// SetAllowCrossOrigin 设置是否允许跨域
-func SetAllowCrossOrigin(allow bool) {
-	handlerMgr.setAllowCrossOrigin(allow)
-}
-
-const (
-	credentialsTrue               = "true"
-	defaultOriginValue            = "*"
-	originRequestHeader           = "Origin"
-	accessControlRequestHeaders   = "Access-Control-Request-Headers"
-	accessControlRequestMethod    = "Access-Control-Request-Method"
-	accessControlAllowOrigin      = "Access-Control-Allow-Origin"
-	accessControlAllowCredentials = "Access-Control-Allow-Credentials"
-	accessControlAllowHeaders     = "Access-Control-Allow-Headers"
-	accessControlAllowMethods     = "Access-Control-Allow-Methods"
-)
-
-//处理跨域
-func doAccessOrigin(w http.ResponseWriter, r *http.Request) {
-
-	origin := r.Header.Get(originRequestHeader)
-
-	if len(strings.TrimSpace(origin)) <= 0 {
-		origin = defaultOriginValue
-	}
-
-	w.Header().Set(accessControlAllowOrigin, origin)
-	w.Header().Set(accessControlAllowCredentials, credentialsTrue)
-
-	if r.Method == http.MethodOptions {
-		w.Header().Set(accessControlAllowMethods, r.Header.Get(accessControlRequestMethod))
-		w.Header().Set(accessControlAllowHeaders, r.Header.Get(accessControlRequestHeaders))
-	}
+// SetCORSPolicy 设置全局跨域访问控制策略，替代历史的 SetAllowCrossOrigin(bool)。
+func SetCORSPolicy(policy CORSPolicy) {
+	handlerMgr.setCORSPolicy(policy)
 }
 
 // WrapResponse 将interface{}转为json写入http.ResponseWriter
@@ -242,6 +225,10 @@ func WrapResponse(w http.ResponseWriter, response interface{}, status int, err e
 			}
 			result[handlerMgr.codeFieldName] = status
 			result[handlerMgr.msgFieldName] = err.Error()
+			var bindErr *BindError
+			if errors.As(err, &bindErr) {
+				result["fields"] = bindErr.Fields
+			}
 		} else {
 			result[handlerMgr.dataFieldName] = response
 			result[handlerMgr.codeFieldName] = 0
@@ -256,35 +243,101 @@ func WrapResponse(w http.ResponseWriter, response interface{}, status int, err e
 		}
 	}
 	w.Header().Set("Content-Type", "application/json")
-	if _, isGzip := w.(gzipResponseWriter); !isGzip {
+	if !writerHasDynamicLength(w) {
 		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
 	}
 	w.WriteHeader(status)
 	w.Write(data)
 }
 
+// dynamicLengthWriter 由会动态改变响应体长度的 ResponseWriter 包装类型实现
+// （如 compressionResponseWriter），WrapResponse/Encoder
+// 据此判断是否应跳过写入 Content-Length。
+type dynamicLengthWriter interface {
+	hasDynamicLength() bool
+}
+
+func writerHasDynamicLength(w http.ResponseWriter) bool {
+	dw, ok := w.(dynamicLengthWriter)
+	return ok && dw.hasDynamicLength()
+}
+
 // ExtendHandler http处理函数，对http.handlerFunc的封装。
 // 将interface{}解析为json，填到body并响应。
 // 自动添加http头。
+// 会按 Use 注册的顺序依次包裹已注册的中间件，可与 GZipHandler 以任意顺序组合使用。
 func ExtendHandler(handler func(*http.Request) (interface{}, int, error)) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
+	return extendHandler("", nil, handler)
+}
+
+// ExtendHandlerWithRoute 与 ExtendHandler 功能一致，额外将 route 作为指标与
+// 追踪的标签（而非直接使用 r.URL.Path），避免指标基数膨胀。已通过 RegisterMetrics、
+// RegisterTracer 注册的 MetricsCollector、Tracer 会在每次请求时被调用。
+func ExtendHandlerWithRoute(route string, handler func(*http.Request) (interface{}, int, error)) http.HandlerFunc {
+	return extendHandler(route, nil, handler)
+}
+
+// ExtendHandlerWithCORS 与 ExtendHandler 功能一致，但使用 policy 替代全局通过
+// SetCORSPolicy 配置的跨域策略，用于个别接口需要独立 CORS 规则的场景。
+func ExtendHandlerWithCORS(policy CORSPolicy, handler func(*http.Request) (interface{}, int, error)) http.HandlerFunc {
+	return extendHandler("", &policy, handler)
+}
+
+func extendHandler(route string, corsOverride *CORSPolicy, handler func(*http.Request) (interface{}, int, error)) http.HandlerFunc {
+	core := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		beginTime := time.Now()
 		handlerMgr.doBeginHooks(w, r)
-		if handlerMgr.allowCrossOrigin {
-			doAccessOrigin(w, r)
+
+		policy := handlerMgr.corsPolicy
+		if corsOverride != nil {
+			policy = *corsOverride
 		}
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
+		if corsStatus, handled := policy.apply(w, r); handled {
+			w.WriteHeader(corsStatus)
+			handlerMgr.doEndHooks(w, r, &HandleResult{
+				StatusCode: corsStatus,
+				Cost:       time.Now().Sub(beginTime),
+				RequestID:  RequestIDFromContext(r.Context()),
+			})
 			return
 		}
+
+		var span Span
+		if tracer != nil {
+			var ctx context.Context
+			ctx, span = tracer.StartSpan(r.Context(), route, r)
+			r = r.WithContext(ctx)
+		}
+		if metricsCollector != nil {
+			metricsCollector.Inflight(1)
+			defer metricsCollector.Inflight(-1)
+		}
+
 		data, status, err := handler(r)
-		WrapResponse(w, data, status, err)
 		handleResult := &HandleResult{
 			Data:       data,
 			StatusCode: status,
 			Err:        err,
 			Cost:       time.Now().Sub(beginTime),
+			RequestID:  RequestIDFromContext(r.Context()),
+		}
+		if err != nil || status != http.StatusOK || !writeStream(w, r, data) {
+			handlerMgr.pickEncoder(r).Encode(w, handleResult)
+		}
+
+		if span != nil {
+			span.SetStatus(status, err)
+			span.End()
 		}
+		if metricsCollector != nil {
+			metricsCollector.ObserveRequest(route, r.Method, status, handleResult.Cost)
+		}
+
 		handlerMgr.doEndHooks(w, r, handleResult)
+	})
+	chained := handlerMgr.chain(core)
+	return func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), routeContextKey, route))
+		chained.ServeHTTP(w, r)
 	}
 }
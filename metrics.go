@@ -0,0 +1,194 @@
+package listenerutil
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MetricsCollector 请求指标采集接口，ExtendHandlerWithRoute 在每次请求结束时调用
+// ObserveRequest，在请求开始/结束时调用 Inflight。可注册基于
+// github.com/prometheus/client_golang 等真实客户端的实现；defaultMetricsCollector
+// 提供了一个无需额外依赖、以 Prometheus 文本暴露格式输出的默认实现。
+type MetricsCollector interface {
+	// ObserveRequest 记录一次已完成请求的 method、status 与耗时，route 由调用方
+	// 通过 ExtendHandlerWithRoute 指定，以保证标签基数可控。
+	ObserveRequest(route, method string, status int, d time.Duration)
+	// Inflight 请求开始时传入 1，结束时传入 -1，用于维护进行中请求数的 gauge。
+	Inflight(delta int)
+}
+
+// Tracer 分布式追踪接口，StartSpan 在 ExtendHandlerWithRoute 中对每个请求调用一次，
+// 返回的 ctx 会替换 r.Context() 后传给 handler，返回的 Span 在响应写出后结束。
+type Tracer interface {
+	StartSpan(ctx context.Context, route string, r *http.Request) (context.Context, Span)
+}
+
+// Span 代表一次请求的追踪区间。
+type Span interface {
+	SetStatus(status int, err error)
+	End()
+}
+
+var (
+	metricsCollector MetricsCollector
+	tracer           Tracer
+)
+
+// RegisterMetrics 注册全局 MetricsCollector。
+func RegisterMetrics(collector MetricsCollector) {
+	metricsCollector = collector
+}
+
+// RegisterTracer 注册全局 Tracer。
+func RegisterTracer(t Tracer) {
+	tracer = t
+}
+
+type routeStatusKey struct {
+	route  string
+	method string
+	status int
+}
+
+// defaultDurationBuckets 是 http_request_duration_seconds 直方图的桶上界（秒），
+// 取值沿用 Prometheus client 库的默认桶，覆盖从 5ms 到 10s 的典型请求耗时范围。
+var defaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// defaultMetricsCollector 是不依赖第三方库的默认 MetricsCollector 实现，按
+// Prometheus 的命名与暴露格式维护 http_requests_total、
+// http_request_duration_seconds_bucket/sum/count 以及 in-flight gauge。
+type defaultMetricsCollector struct {
+	mu        sync.Mutex
+	counts    map[routeStatusKey]uint64
+	durations map[routeStatusKey]float64
+	buckets   map[routeStatusKey][]uint64
+	inflight  int64
+}
+
+// NewDefaultMetricsCollector 创建默认的 MetricsCollector，WriteTo 可用于暴露给
+// /metrics 之类的抓取端点。
+func NewDefaultMetricsCollector() *defaultMetricsCollector {
+	return &defaultMetricsCollector{
+		counts:    make(map[routeStatusKey]uint64),
+		durations: make(map[routeStatusKey]float64),
+		buckets:   make(map[routeStatusKey][]uint64),
+	}
+}
+
+func (c *defaultMetricsCollector) ObserveRequest(route, method string, status int, d time.Duration) {
+	key := routeStatusKey{route: route, method: method, status: status}
+	seconds := d.Seconds()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[key]++
+	c.durations[key] += seconds
+	bucketCounts, ok := c.buckets[key]
+	if !ok {
+		bucketCounts = make([]uint64, len(defaultDurationBuckets))
+		c.buckets[key] = bucketCounts
+	}
+	for i, le := range defaultDurationBuckets {
+		if seconds <= le {
+			bucketCounts[i]++
+		}
+	}
+}
+
+func (c *defaultMetricsCollector) Inflight(delta int) {
+	atomic.AddInt64(&c.inflight, int64(delta))
+}
+
+// WriteTo 以 Prometheus 文本暴露格式输出已采集的指标，包含可被 histogram_quantile
+// 查询的 _bucket{le="..."} 累积分布（以 +Inf 桶收尾）。
+func (c *defaultMetricsCollector) WriteTo(w http.ResponseWriter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "http_requests_in_flight %d\n", atomic.LoadInt64(&c.inflight))
+	for key, count := range c.counts {
+		labels := fmt.Sprintf(`method=%q,path=%q,status=%q`, key.method, key.route, strconv.Itoa(key.status))
+		fmt.Fprintf(w, "http_requests_total{%s} %d\n", labels, count)
+		bucketCounts := c.buckets[key]
+		for i, le := range defaultDurationBuckets {
+			fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=%q} %d\n", labels, strconv.FormatFloat(le, 'g', -1, 64), bucketCounts[i])
+		}
+		fmt.Fprintf(w, "http_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, count)
+		fmt.Fprintf(w, "http_request_duration_seconds_sum{%s} %v\n", labels, c.durations[key])
+		fmt.Fprintf(w, "http_request_duration_seconds_count{%s} %d\n", labels, count)
+	}
+}
+
+const traceparentHeader = "traceparent"
+
+// defaultTracer 是不依赖 OpenTelemetry SDK 的默认 Tracer 实现，按 W3C Trace
+// Context 规范解析/生成 traceparent 头，span 上下文通过 r.Context() 向下游传递。
+type defaultTracer struct{}
+
+// NewDefaultTracer 创建默认的 Tracer。
+func NewDefaultTracer() defaultTracer {
+	return defaultTracer{}
+}
+
+type traceContextKey struct{}
+
+type traceContext struct {
+	traceID string
+	spanID  string
+}
+
+func (defaultTracer) StartSpan(ctx context.Context, route string, r *http.Request) (context.Context, Span) {
+	traceID, parentSpanID := parseTraceparent(r.Header.Get(traceparentHeader))
+	if len(traceID) == 0 {
+		traceID = generateRequestID()
+	}
+	spanID := generateRequestID()[:16]
+
+	tc := traceContext{traceID: traceID, spanID: spanID}
+	ctx = context.WithValue(ctx, traceContextKey{}, tc)
+
+	_ = parentSpanID
+	span := &defaultSpan{route: route, traceID: traceID, spanID: spanID, start: time.Now()}
+	return ctx, span
+}
+
+// TraceparentFromContext 返回当前请求的 W3C traceparent 头，未启用 Tracer 时返回空串。
+func TraceparentFromContext(ctx context.Context) string {
+	tc, ok := ctx.Value(traceContextKey{}).(traceContext)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", tc.traceID, tc.spanID)
+}
+
+func parseTraceparent(header string) (traceID, spanID string) {
+	parts := strings.Split(header, "-")
+	if len(parts) != 4 {
+		return "", ""
+	}
+	return parts[1], parts[2]
+}
+
+type defaultSpan struct {
+	route   string
+	traceID string
+	spanID  string
+	start   time.Time
+	status  int
+	err     error
+}
+
+func (s *defaultSpan) SetStatus(status int, err error) {
+	s.status = status
+	s.err = err
+}
+
+func (s *defaultSpan) End() {
+	// 默认实现仅维护 span 的生命周期与状态，不做任何导出；
+	// 接入真实的 OTel SDK 时应替换为注册自定义 Tracer。
+}
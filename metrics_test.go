@@ -0,0 +1,101 @@
+package listenerutil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultMetricsCollectorWriteToEmitsHistogramBuckets(t *testing.T) {
+	c := NewDefaultMetricsCollector()
+	c.Inflight(1)
+	c.ObserveRequest("/users/:id", http.MethodGet, http.StatusOK, 20*time.Millisecond)
+
+	w := httptest.NewRecorder()
+	c.WriteTo(w)
+	body := w.Body.String()
+
+	if !strings.Contains(body, `http_requests_in_flight 1`) {
+		t.Fatalf("missing in-flight gauge: %s", body)
+	}
+	if !strings.Contains(body, `http_requests_total{method="GET",path="/users/:id",status="200"} 1`) {
+		t.Fatalf("missing request counter: %s", body)
+	}
+	if !strings.Contains(body, `http_request_duration_seconds_sum{`) {
+		t.Fatalf("missing duration sum: %s", body)
+	}
+	if !strings.Contains(body, `http_request_duration_seconds_count{`) {
+		t.Fatalf("missing duration count: %s", body)
+	}
+
+	// A valid Prometheus histogram needs cumulative _bucket lines for every
+	// configured boundary plus a terminal +Inf bucket equal to the total count.
+	for _, le := range defaultDurationBuckets {
+		want := `le="` + strconv.FormatFloat(le, 'g', -1, 64) + `"`
+		if !strings.Contains(body, want) {
+			t.Fatalf("missing bucket %s: %s", want, body)
+		}
+	}
+	if !strings.Contains(body, `le="+Inf"} 1`) {
+		t.Fatalf("missing +Inf bucket: %s", body)
+	}
+}
+
+func TestDefaultMetricsCollectorBucketsAreCumulative(t *testing.T) {
+	c := NewDefaultMetricsCollector()
+	c.ObserveRequest("/r", http.MethodGet, http.StatusOK, 7*time.Second)
+
+	key := routeStatusKey{route: "/r", method: http.MethodGet, status: http.StatusOK}
+	buckets := c.buckets[key]
+	for i, le := range defaultDurationBuckets {
+		if le < 7 && buckets[i] != 0 {
+			t.Fatalf("bucket le=%v should not count a 7s observation, got %d", le, buckets[i])
+		}
+		if le >= 7 && buckets[i] != 1 {
+			t.Fatalf("bucket le=%v should count a 7s observation, got %d", le, buckets[i])
+		}
+	}
+}
+
+func TestDefaultTracerStartSpanRoundTripsTraceparent(t *testing.T) {
+	tracer := NewDefaultTracer()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, span := tracer.StartSpan(context.Background(), "/r", r)
+	if span == nil {
+		t.Fatal("expected a non-nil span")
+	}
+
+	tp := TraceparentFromContext(ctx)
+	if !strings.HasPrefix(tp, "00-4bf92f3577b34da6a3ce929d0e0e4736-") {
+		t.Fatalf("expected generated traceparent to reuse incoming trace ID, got %q", tp)
+	}
+	if !strings.HasSuffix(tp, "-01") {
+		t.Fatalf("expected traceparent flags suffix -01, got %q", tp)
+	}
+
+	span.SetStatus(http.StatusOK, nil)
+	span.End()
+}
+
+func TestDefaultTracerStartSpanGeneratesTraceIDWhenAbsent(t *testing.T) {
+	tracer := NewDefaultTracer()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	ctx, _ := tracer.StartSpan(context.Background(), "/r", r)
+	tp := TraceparentFromContext(ctx)
+	if len(tp) == 0 {
+		t.Fatal("expected a generated traceparent even without an incoming header")
+	}
+}
+
+func TestTraceparentFromContextEmptyWithoutSpan(t *testing.T) {
+	if tp := TraceparentFromContext(context.Background()); tp != "" {
+		t.Fatalf("expected empty traceparent without a started span, got %q", tp)
+	}
+}
@@ -0,0 +1,116 @@
+package listenerutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type contextKey string
+
+const requestIDContextKey contextKey = "listenerutil.requestID"
+const routeContextKey contextKey = "listenerutil.route"
+
+const requestIDHeader = "X-Request-Id"
+
+// Use 注册一个中间件，以 func(next http.Handler) http.Handler 的标准形式包裹
+// ExtendHandler 的处理逻辑。多次调用按注册顺序由外到内包裹，最先注册的最先执行，
+// 须在创建 ExtendHandler 之前完成注册。
+func Use(mw func(next http.Handler) http.Handler) {
+	handlerMgr.use(mw)
+}
+
+func (handlerMgr *handlerManager) use(mw func(next http.Handler) http.Handler) {
+	handlerMgr.middlewares = append(handlerMgr.middlewares, mw)
+}
+
+// chain 按注册顺序由外到内依次包裹 final。
+func (handlerMgr *handlerManager) chain(final http.Handler) http.Handler {
+	h := final
+	for i := len(handlerMgr.middlewares) - 1; i >= 0; i-- {
+		h = handlerMgr.middlewares[i](h)
+	}
+	return h
+}
+
+// RequestIDFromContext 获取由 RequestIDMiddleware 注入的请求 ID，未注入时返回空字符串。
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+// RouteFromContext 获取 ExtendHandler/ExtendHandlerWithRoute 注册时确定的 route 标签，
+// 用于 Use 注册的中间件（如 RecoverMiddleware）以有界基数上报指标，避免直接使用
+// r.URL.Path。未经 extendHandler 注入时返回空字符串。
+func RouteFromContext(ctx context.Context) string {
+	route, _ := ctx.Value(routeContextKey).(string)
+	return route
+}
+
+// RequestIDMiddleware 读取请求头 X-Request-Id 作为请求 ID，不存在时生成一个，
+// 存入 r.Context() 并写回响应头，供下游 handler 及 end hook 关联访问日志使用。
+func RequestIDMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(requestIDHeader)
+			if len(id) == 0 {
+				id = generateRequestID()
+			}
+			w.Header().Set(requestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDContextKey, id)))
+		})
+	}
+}
+
+// TimeoutMiddleware 为请求的 context 设置 d 超时，handler 可通过
+// r.Context().Done()/Err() 感知超时并提前返回。
+func TimeoutMiddleware(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RecoverMiddleware 捕获 handler 执行中的 panic，转换为模块标准的错误响应，避免进程崩溃。
+// panic 会越过 extendHandler 中 handler 调用之后的 span/metrics/doEndHooks 逻辑，
+// 因此这里额外合成一个 HandleResult 并驱动同样的 end hook 与 metrics 上报，
+// 避免 panic 这一最需要留痕的事件反而在访问日志与指标中完全不可见。
+func RecoverMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			beginTime := time.Now()
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				handleResult := &HandleResult{
+					StatusCode: http.StatusInternalServerError,
+					Err:        fmt.Errorf("panic: %v", rec),
+					Cost:       time.Since(beginTime),
+					RequestID:  RequestIDFromContext(r.Context()),
+				}
+				handlerMgr.pickEncoder(r).Encode(w, handleResult)
+				if metricsCollector != nil {
+					metricsCollector.ObserveRequest(RouteFromContext(r.Context()), r.Method, handleResult.StatusCode, handleResult.Cost)
+				}
+				handlerMgr.doEndHooks(w, r, handleResult)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func generateRequestID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,135 @@
+package listenerutil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandlerManagerChainRunsMiddlewaresOuterToInner(t *testing.T) {
+	mgr := &handlerManager{}
+	var order []string
+	mgr.use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			order = append(order, "first-before")
+			next.ServeHTTP(w, r)
+			order = append(order, "first-after")
+		})
+	})
+	mgr.use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			order = append(order, "second-before")
+			next.ServeHTTP(w, r)
+			order = append(order, "second-after")
+		})
+	})
+	final := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "final")
+	})
+
+	mgr.chain(final).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	want := []string{"first-before", "second-before", "final", "second-after", "first-after"}
+	if len(order) != len(want) {
+		t.Fatalf("unexpected call order: %v", order)
+	}
+	for i, step := range want {
+		if order[i] != step {
+			t.Fatalf("unexpected call order: %v", order)
+		}
+	}
+}
+
+func TestRequestIDMiddlewareGeneratesAndReusesID(t *testing.T) {
+	var seen string
+	handler := RequestIDMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestIDFromContext(r.Context())
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	handler.ServeHTTP(w, r)
+
+	if len(seen) == 0 {
+		t.Fatal("expected a generated request ID")
+	}
+	if got := w.Header().Get(requestIDHeader); got != seen {
+		t.Fatalf("expected response header to echo generated ID, got %q want %q", got, seen)
+	}
+
+	w2 := httptest.NewRecorder()
+	r2 := httptest.NewRequest(http.MethodGet, "/", nil)
+	r2.Header.Set(requestIDHeader, "client-supplied-id")
+	handler.ServeHTTP(w2, r2)
+	if seen != "client-supplied-id" {
+		t.Fatalf("expected client-supplied request ID to be reused, got %q", seen)
+	}
+}
+
+func TestTimeoutMiddlewarePropagatesDeadlineToContext(t *testing.T) {
+	var deadlineSet bool
+	handler := TimeoutMiddleware(50 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, deadlineSet = r.Context().Deadline()
+		<-r.Context().Done()
+	}))
+
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	elapsed := time.Since(start)
+
+	if !deadlineSet {
+		t.Fatal("expected handler's context to carry a deadline")
+	}
+	if elapsed > time.Second {
+		t.Fatalf("expected context to time out quickly, took %v", elapsed)
+	}
+}
+
+func TestRecoverMiddlewareConvertsPanicToResponse(t *testing.T) {
+	handler := RecoverMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	handler.ServeHTTP(w, r)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", w.Code)
+	}
+}
+
+type recordingMetricsCollector struct {
+	routes []string
+}
+
+func (c *recordingMetricsCollector) ObserveRequest(route, method string, status int, d time.Duration) {
+	c.routes = append(c.routes, route)
+}
+
+func (c *recordingMetricsCollector) Inflight(delta int) {}
+
+// TestRecoverMiddlewareReportsBoundedRouteLabelOnPanic 验证 panic 时上报给
+// MetricsCollector 的 route 标签来自 context 中 extendHandler 注入的值，而非
+// 未经界定的 r.URL.Path。
+func TestRecoverMiddlewareReportsBoundedRouteLabelOnPanic(t *testing.T) {
+	collector := &recordingMetricsCollector{}
+	prev := metricsCollector
+	metricsCollector = collector
+	defer func() { metricsCollector = prev }()
+
+	handler := RecoverMiddleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/users/12345", nil)
+	r = r.WithContext(context.WithValue(r.Context(), routeContextKey, "/users/:id"))
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if len(collector.routes) != 1 || collector.routes[0] != "/users/:id" {
+		t.Fatalf("expected bounded route label %q, got %v", "/users/:id", collector.routes)
+	}
+}
@@ -0,0 +1,305 @@
+package listenerutil
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	contentTypeMsgpack  = "application/msgpack"
+	contentTypeProtobuf = "application/x-protobuf"
+)
+
+// msgpackEncoder 将响应按 legacyEncoder 同样的 envelope 结构编码为 MessagePack
+// 二进制格式，供 Accept: application/msgpack 的调用方使用。
+type msgpackEncoder struct{}
+
+func (msgpackEncoder) ContentType() string { return contentTypeMsgpack }
+
+func (msgpackEncoder) Encode(w http.ResponseWriter, result *HandleResult) error {
+	status := result.StatusCode
+	err := result.Err
+	body := make(map[string]interface{}, 2)
+	if err != nil || status != http.StatusOK {
+		if status == http.StatusOK {
+			status = http.StatusBadRequest
+		}
+		if err == nil {
+			err = errors.New(http.StatusText(status))
+		}
+		body[handlerMgr.codeFieldName] = status
+		body[handlerMgr.msgFieldName] = err.Error()
+		var bindErr *BindError
+		if errors.As(err, &bindErr) {
+			body["fields"] = bindErr.Fields
+		}
+	} else {
+		body[handlerMgr.dataFieldName] = result.Data
+		body[handlerMgr.codeFieldName] = 0
+	}
+
+	data, encErr := marshalMsgpack(body)
+	if encErr != nil {
+		return encErr
+	}
+	return writeRawBody(w, contentTypeMsgpack, status, data)
+}
+
+// protobufMarshaler 是常见 protobuf 生成代码都会实现的最小接口，
+// protobufEncoder 在 result.Data 实现了该接口时直接使用其序列化结果。
+type protobufMarshaler interface {
+	Marshal() ([]byte, error)
+}
+
+// protobufEncoder 将响应编码为 application/x-protobuf。由于任意 Go 值在没有
+// .proto 消息定义的情况下无法确定字段编号与类型，成功响应仅在 result.Data 实现
+// 了 protobufMarshaler 时才能真正编码为 protobuf，否则回退到 legacyEncoder；
+// 错误响应固定按 {1: status(varint), 2: message(string)} 编码为合法的 protobuf wire格式。
+type protobufEncoder struct{}
+
+func (protobufEncoder) ContentType() string { return contentTypeProtobuf }
+
+func (protobufEncoder) Encode(w http.ResponseWriter, result *HandleResult) error {
+	status := result.StatusCode
+	err := result.Err
+	if err != nil || status != http.StatusOK {
+		if status == http.StatusOK {
+			status = http.StatusBadRequest
+		}
+		if err == nil {
+			err = errors.New(http.StatusText(status))
+		}
+		data := encodeProtobufError(status, err.Error())
+		return writeRawBody(w, contentTypeProtobuf, status, data)
+	}
+
+	if marshaler, ok := result.Data.(protobufMarshaler); ok {
+		data, marshalErr := marshaler.Marshal()
+		if marshalErr != nil {
+			return marshalErr
+		}
+		return writeRawBody(w, contentTypeProtobuf, status, data)
+	}
+	// result.Data 不是 protobuf 消息，无法在没有 schema 的情况下生成 protobuf 字节，
+	// 回退到默认 envelope，保证调用方至少能拿到可解析的响应。
+	return legacyEncoder{}.Encode(w, result)
+}
+
+func encodeProtobufError(status int, message string) []byte {
+	var buf []byte
+	buf = appendProtobufVarintField(buf, 1, uint64(status))
+	buf = appendProtobufStringField(buf, 2, message)
+	return buf
+}
+
+func appendProtobufVarintField(buf []byte, fieldNum int, value uint64) []byte {
+	buf = appendVarint(buf, uint64(fieldNum)<<3)
+	return appendVarint(buf, value)
+}
+
+func appendProtobufStringField(buf []byte, fieldNum int, s string) []byte {
+	buf = appendVarint(buf, uint64(fieldNum)<<3|2)
+	buf = appendVarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+func writeRawBody(w http.ResponseWriter, contentType string, status int, data []byte) error {
+	w.Header().Set("Content-Type", contentType)
+	if !writerHasDynamicLength(w) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	}
+	w.WriteHeader(status)
+	w.Write(data)
+	return nil
+}
+
+// marshalMsgpack 将任意 Go 值编码为 MessagePack 二进制格式，支持 envelope 中
+// 实际出现的 nil/bool/数值/字符串/slice/map/struct。
+func marshalMsgpack(v interface{}) ([]byte, error) {
+	return appendMsgpackValue(nil, reflect.ValueOf(v))
+}
+
+func appendMsgpackValue(buf []byte, v reflect.Value) ([]byte, error) {
+	if !v.IsValid() {
+		return append(buf, 0xc0), nil
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return append(buf, 0xc0), nil
+		}
+		return appendMsgpackValue(buf, v.Elem())
+	case reflect.Bool:
+		if v.Bool() {
+			return append(buf, 0xc3), nil
+		}
+		return append(buf, 0xc2), nil
+	case reflect.String:
+		return appendMsgpackString(buf, v.String()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendMsgpackInt(buf, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return appendMsgpackUint(buf, v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return appendMsgpackFloat(buf, v.Float()), nil
+	case reflect.Slice, reflect.Array:
+		return appendMsgpackArray(buf, v)
+	case reflect.Map:
+		return appendMsgpackMap(buf, v)
+	case reflect.Struct:
+		return appendMsgpackStruct(buf, v)
+	default:
+		return nil, fmt.Errorf("msgpack: unsupported kind %s", v.Kind())
+	}
+}
+
+func appendMsgpackInt(buf []byte, n int64) []byte {
+	if n >= 0 && n <= 0x7f {
+		return append(buf, byte(n))
+	}
+	if n < 0 && n >= -32 {
+		return append(buf, byte(n))
+	}
+	buf = append(buf, 0xd3)
+	return appendUint64BE(buf, uint64(n))
+}
+
+// appendMsgpackUint 编码无符号整数，超出 positive fixint 范围时使用 0xcf（uint64）
+// 标记，而非 appendMsgpackInt 的 0xd3（int64）标记——二者线上字节位模式相同，但
+// 标准解码器会按标记中声明的符号解读，误用 0xd3 会让超过 math.MaxInt64 的值被
+// 解出为负数。
+func appendMsgpackUint(buf []byte, n uint64) []byte {
+	if n <= 0x7f {
+		return append(buf, byte(n))
+	}
+	buf = append(buf, 0xcf)
+	return appendUint64BE(buf, n)
+}
+
+func appendMsgpackFloat(buf []byte, f float64) []byte {
+	buf = append(buf, 0xcb)
+	return appendUint64BE(buf, math.Float64bits(f))
+}
+
+func appendUint64BE(buf []byte, n uint64) []byte {
+	return append(buf, byte(n>>56), byte(n>>48), byte(n>>40), byte(n>>32), byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+func appendMsgpackString(buf []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n <= 31:
+		buf = append(buf, 0xa0|byte(n))
+	case n <= 0xff:
+		buf = append(buf, 0xd9, byte(n))
+	case n <= 0xffff:
+		buf = append(buf, 0xda, byte(n>>8), byte(n))
+	default:
+		buf = append(buf, 0xdb, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(buf, s...)
+}
+
+func appendMsgpackArray(buf []byte, v reflect.Value) ([]byte, error) {
+	n := v.Len()
+	buf = appendMsgpackArrayHeader(buf, n)
+	var err error
+	for i := 0; i < n; i++ {
+		buf, err = appendMsgpackValue(buf, v.Index(i))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func appendMsgpackArrayHeader(buf []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(buf, 0x90|byte(n))
+	case n <= 0xffff:
+		return append(buf, 0xdc, byte(n>>8), byte(n))
+	default:
+		return append(buf, 0xdd, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+}
+
+func appendMsgpackMap(buf []byte, v reflect.Value) ([]byte, error) {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	buf = appendMsgpackMapHeader(buf, len(keys))
+	var err error
+	for _, k := range keys {
+		buf, err = appendMsgpackValue(buf, k)
+		if err != nil {
+			return nil, err
+		}
+		buf, err = appendMsgpackValue(buf, v.MapIndex(k))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func appendMsgpackMapHeader(buf []byte, n int) []byte {
+	switch {
+	case n <= 15:
+		return append(buf, 0x80|byte(n))
+	case n <= 0xffff:
+		return append(buf, 0xde, byte(n>>8), byte(n))
+	default:
+		return append(buf, 0xdf, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+	}
+}
+
+func appendMsgpackStruct(buf []byte, v reflect.Value) ([]byte, error) {
+	t := v.Type()
+	type field struct {
+		key string
+		val reflect.Value
+	}
+	fields := make([]field, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if n := strings.Split(tag, ",")[0]; len(n) > 0 {
+				name = n
+			}
+		}
+		fields = append(fields, field{key: name, val: v.Field(i)})
+	}
+	buf = appendMsgpackMapHeader(buf, len(fields))
+	var err error
+	for _, f := range fields {
+		buf, err = appendMsgpackValue(buf, reflect.ValueOf(f.key))
+		if err != nil {
+			return nil, err
+		}
+		buf, err = appendMsgpackValue(buf, f.val)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
@@ -0,0 +1,231 @@
+package listenerutil
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAppendMsgpackUintUsesUint64TagNotInt64Tag(t *testing.T) {
+	buf := appendMsgpackUint(nil, math.MaxUint64)
+	if len(buf) != 9 {
+		t.Fatalf("expected a 1-byte tag + 8-byte value, got %d bytes", len(buf))
+	}
+	if buf[0] != 0xcf {
+		t.Fatalf("expected msgpack uint64 tag 0xcf, got 0x%x", buf[0])
+	}
+	if got := binary.BigEndian.Uint64(buf[1:]); got != math.MaxUint64 {
+		t.Fatalf("unexpected encoded value: %d", got)
+	}
+}
+
+func TestAppendMsgpackUintSmallValueUsesPositiveFixint(t *testing.T) {
+	buf := appendMsgpackUint(nil, 42)
+	if len(buf) != 1 || buf[0] != 42 {
+		t.Fatalf("expected a single positive fixint byte, got % x", buf)
+	}
+}
+
+func TestAppendMsgpackValueEncodesLargeUintViaUintPath(t *testing.T) {
+	var n uint64 = math.MaxInt64 + 1
+	buf, err := appendMsgpackValue(nil, reflect.ValueOf(n))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf[0] != 0xcf {
+		t.Fatalf("expected msgpack uint64 tag 0xcf, got 0x%x", buf[0])
+	}
+}
+
+func TestMarshalMsgpackStructUsesJSONFieldNamesAsMapKeys(t *testing.T) {
+	data, err := marshalMsgpack(bindTestParam{Name: "alice", Age: 30})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	decoded, _, err := decodeMsgpackValue(data)
+	if err != nil {
+		t.Fatalf("failed to decode: %v", err)
+	}
+	m, ok := decoded.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected decoded value to be a map, got %T", decoded)
+	}
+	if m["name"] != "alice" {
+		t.Fatalf("unexpected name: %v", m["name"])
+	}
+	if m["age"] != int64(30) {
+		t.Fatalf("unexpected age: %v (%T)", m["age"], m["age"])
+	}
+}
+
+func TestMsgpackEncoderEncodesSuccessEnvelope(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := msgpackEncoder{}.Encode(w, &HandleResult{Data: "hello", StatusCode: 200})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != contentTypeMsgpack {
+		t.Fatalf("unexpected Content-Type: %q", ct)
+	}
+	decoded, _, decErr := decodeMsgpackValue(w.Body.Bytes())
+	if decErr != nil {
+		t.Fatalf("failed to decode: %v", decErr)
+	}
+	m := decoded.(map[string]interface{})
+	if m[handlerMgr.dataFieldName] != "hello" {
+		t.Fatalf("unexpected data field: %v", m[handlerMgr.dataFieldName])
+	}
+	if m[handlerMgr.codeFieldName] != int64(0) {
+		t.Fatalf("unexpected code field: %v", m[handlerMgr.codeFieldName])
+	}
+}
+
+func TestProtobufEncoderErrorEnvelopeIsValidWireFormat(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := protobufEncoder{}.Encode(w, &HandleResult{StatusCode: 400, Err: errors.New("bad request")})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != contentTypeProtobuf {
+		t.Fatalf("unexpected Content-Type: %q", ct)
+	}
+	status, message, decErr := decodeProtobufErrorFields(w.Body.Bytes())
+	if decErr != nil {
+		t.Fatalf("failed to decode: %v", decErr)
+	}
+	if status != 400 || message != "bad request" {
+		t.Fatalf("unexpected fields: status=%d message=%q", status, message)
+	}
+}
+
+func TestProtobufEncoderFallsBackWithoutMarshaler(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := protobufEncoder{}.Encode(w, &HandleResult{Data: "no marshaler", StatusCode: 200})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != contentTypeJSON {
+		t.Fatalf("expected fallback to legacyEncoder's Content-Type, got %q", ct)
+	}
+}
+
+// --- minimal test-only helpers: a structural msgpack/protobuf decoder ---
+// No third-party msgpack/protobuf libraries are available in this sandbox, so these
+// decoders only need to understand the subset of the wire format produced above.
+
+func decodeMsgpackValue(buf []byte) (interface{}, []byte, error) {
+	if len(buf) == 0 {
+		return nil, nil, errors.New("unexpected end of input")
+	}
+	b := buf[0]
+	switch {
+	case b == 0xc0:
+		return nil, buf[1:], nil
+	case b == 0xc2:
+		return false, buf[1:], nil
+	case b == 0xc3:
+		return true, buf[1:], nil
+	case b <= 0x7f:
+		return int64(b), buf[1:], nil
+	case b >= 0xe0:
+		return int64(int8(b)), buf[1:], nil
+	case b == 0xcf:
+		return int64(binary.BigEndian.Uint64(buf[1:9])), buf[9:], nil
+	case b == 0xd3:
+		return int64(binary.BigEndian.Uint64(buf[1:9])), buf[9:], nil
+	case b == 0xcb:
+		return math.Float64frombits(binary.BigEndian.Uint64(buf[1:9])), buf[9:], nil
+	case b&0xe0 == 0xa0:
+		n := int(b & 0x1f)
+		return string(buf[1 : 1+n]), buf[1+n:], nil
+	case b == 0xd9:
+		n := int(buf[1])
+		return string(buf[2 : 2+n]), buf[2+n:], nil
+	case b&0xf0 == 0x80:
+		return decodeMsgpackMap(int(b&0x0f), buf[1:])
+	case b == 0xde:
+		n := int(binary.BigEndian.Uint16(buf[1:3]))
+		return decodeMsgpackMap(n, buf[3:])
+	case b&0xf0 == 0x90:
+		return decodeMsgpackArray(int(b&0x0f), buf[1:])
+	}
+	return nil, nil, errors.New("unsupported msgpack tag in test decoder")
+}
+
+func decodeMsgpackMap(n int, buf []byte) (interface{}, []byte, error) {
+	m := make(map[string]interface{}, n)
+	rest := buf
+	for i := 0; i < n; i++ {
+		var key, val interface{}
+		var err error
+		key, rest, err = decodeMsgpackValue(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		val, rest, err = decodeMsgpackValue(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		m[key.(string)] = val
+	}
+	return m, rest, nil
+}
+
+func decodeMsgpackArray(n int, buf []byte) (interface{}, []byte, error) {
+	arr := make([]interface{}, 0, n)
+	rest := buf
+	for i := 0; i < n; i++ {
+		var val interface{}
+		var err error
+		val, rest, err = decodeMsgpackValue(rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		arr = append(arr, val)
+	}
+	return arr, rest, nil
+}
+
+func decodeProtobufErrorFields(buf []byte) (status int, message string, err error) {
+	for len(buf) > 0 {
+		tag, n := decodeVarint(buf)
+		buf = buf[n:]
+		fieldNum := tag >> 3
+		wireType := tag & 0x7
+		switch wireType {
+		case 0:
+			v, n := decodeVarint(buf)
+			buf = buf[n:]
+			if fieldNum == 1 {
+				status = int(v)
+			}
+		case 2:
+			l, n := decodeVarint(buf)
+			buf = buf[n:]
+			s := string(buf[:l])
+			buf = buf[l:]
+			if fieldNum == 2 {
+				message = s
+			}
+		default:
+			return 0, "", errors.New("unsupported protobuf wire type in test decoder")
+		}
+	}
+	return status, message, nil
+}
+
+func decodeVarint(buf []byte) (uint64, int) {
+	var v uint64
+	var shift uint
+	for i, b := range buf {
+		v |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return v, i + 1
+		}
+		shift += 7
+	}
+	return v, len(buf)
+}
@@ -0,0 +1,147 @@
+package listenerutil
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// Streamer 由 handler 的返回值实现，用于接管响应体的写入，跳过 Encoder 的 envelope
+// 封装，适用于无法一次性物化到内存的场景（SSE、NDJSON、大文件导出等）。
+type Streamer interface {
+	WriteTo(w http.ResponseWriter) error
+}
+
+// writeStream 尝试以流式方式写入 data，处理了则返回 true；
+// data 不是可流式类型时返回 false，交由 Encoder 按 envelope 方式处理。
+// r.Context() 被传入 streamReader/streamChan，客户端断开（ctx.Done()）时提前终止写入。
+func writeStream(w http.ResponseWriter, r *http.Request, data interface{}) bool {
+	switch v := data.(type) {
+	case Streamer:
+		if err := v.WriteTo(w); err != nil {
+			fmt.Println("ExtendHandler: stream failed:", err)
+		}
+		return true
+	case io.Reader:
+		streamReader(w, r.Context(), v)
+		return true
+	}
+	if ch, ok := asReceivableChan(r.Context(), data); ok {
+		streamChan(w, r.Context(), ch)
+		return true
+	}
+	return false
+}
+
+// asReceivableChan 识别 handler 返回的 chan interface{}（通常是双向 channel，
+// 如 make(chan interface{})），而不只是 <-chan interface{} 这一种具体类型；
+// 通过反射在后台 goroutine 中将其转发到一个真正的 <-chan interface{} 供 streamChan 消费。
+// ctx 取消（消费者不再读取）时转发 goroutine 随之退出，避免其无限期阻塞在 v.Recv() 或向
+// out 发送上。
+func asReceivableChan(ctx context.Context, data interface{}) (<-chan interface{}, bool) {
+	if ch, ok := data.(<-chan interface{}); ok {
+		return ch, true
+	}
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Chan || v.Type().ChanDir() == reflect.SendDir {
+		return nil, false
+	}
+	if v.Type().Elem() != reflect.TypeOf((*interface{})(nil)).Elem() {
+		return nil, false
+	}
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		done := reflect.ValueOf(ctx.Done())
+		cases := []reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: done},
+			{Dir: reflect.SelectRecv, Chan: v},
+		}
+		for {
+			chosen, item, ok := reflect.Select(cases)
+			if chosen == 0 || !ok {
+				return
+			}
+			select {
+			case out <- item.Interface():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, true
+}
+
+// streamReader 将 r 中的内容按块写入 w，每次写入后尝试 flush，不设置 Content-Length。
+// ctx 取消或写入失败时提前返回，避免在客户端断开后继续读取/写入。
+func streamReader(w http.ResponseWriter, ctx context.Context, r io.Reader) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+	flusher, canFlush := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			if canFlush {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				fmt.Println("ExtendHandler: stream read failed:", err)
+			}
+			return
+		}
+	}
+}
+
+// streamChan 将 channel 中每个元素编码为一行 json 并写入 w（NDJSON），逐条 flush。
+// ctx 取消或写入失败时提前返回并停止消费 ch，使 asReceivableChan 的转发 goroutine
+// （若有）能随之退出。
+func streamChan(w http.ResponseWriter, ctx context.Context, ch <-chan interface{}) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	flusher, canFlush := w.(http.Flusher)
+	bw := bufio.NewWriter(w)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case item, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(item)
+			if err != nil {
+				fmt.Println("ExtendHandler: stream marshal failed:", err)
+				continue
+			}
+			if _, err := bw.Write(data); err != nil {
+				return
+			}
+			if err := bw.WriteByte('\n'); err != nil {
+				return
+			}
+			if err := bw.Flush(); err != nil {
+				return
+			}
+			if canFlush {
+				flusher.Flush()
+			}
+		}
+	}
+}
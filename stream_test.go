@@ -0,0 +1,148 @@
+package listenerutil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeStreamer struct {
+	written string
+	err     error
+}
+
+func (s *fakeStreamer) WriteTo(w http.ResponseWriter) error {
+	if s.err != nil {
+		return s.err
+	}
+	_, err := w.Write([]byte(s.written))
+	return err
+}
+
+func TestWriteStreamHandlesStreamer(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	handled := writeStream(w, r, &fakeStreamer{written: "hello"})
+	if !handled {
+		t.Fatal("expected Streamer to be handled")
+	}
+	if w.Body.String() != "hello" {
+		t.Fatalf("unexpected body: %q", w.Body.String())
+	}
+}
+
+func TestWriteStreamHandlesIOReader(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	handled := writeStream(w, r, strings.NewReader("chunked body"))
+	if !handled {
+		t.Fatal("expected io.Reader to be handled")
+	}
+	if w.Body.String() != "chunked body" {
+		t.Fatalf("unexpected body: %q", w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/octet-stream" {
+		t.Fatalf("unexpected Content-Type: %q", ct)
+	}
+}
+
+func TestWriteStreamHandlesReceiveOnlyChan(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	ch := make(chan interface{}, 2)
+	ch <- map[string]interface{}{"n": float64(1)}
+	ch <- map[string]interface{}{"n": float64(2)}
+	close(ch)
+
+	handled := writeStream(w, r, (<-chan interface{})(ch))
+	if !handled {
+		t.Fatal("expected <-chan interface{} to be handled")
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 NDJSON lines, got %d: %q", len(lines), w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("unexpected Content-Type: %q", ct)
+	}
+}
+
+func TestWriteStreamHandlesBidirectionalChan(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	ch := make(chan interface{})
+	go func() {
+		ch <- "a"
+		ch <- "b"
+		close(ch)
+	}()
+
+	handled := writeStream(w, r, ch)
+	if !handled {
+		t.Fatal("expected bidirectional chan interface{} to be handled")
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 NDJSON lines, got %d: %q", len(lines), w.Body.String())
+	}
+}
+
+func TestWriteStreamReturnsFalseForPlainData(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if writeStream(w, r, map[string]interface{}{"x": 1}) {
+		t.Fatal("expected plain data to not be treated as a stream")
+	}
+}
+
+// TestStreamChanStopsOnContextCancellation 验证客户端断开（ctx 被取消）时
+// streamChan 会停止消费 channel 并及时返回，而不是无限期阻塞或继续写入。
+func TestStreamChanStopsOnContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan interface{})
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		streamChan(w, ctx, ch)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("streamChan did not return after context cancellation")
+	}
+}
+
+// TestAsReceivableChanStopsForwardingOnContextCancellation 验证消费者停止读取
+// （ctx 取消）后，asReceivableChan 为双向 channel 启动的转发 goroutine 会退出，
+// 而不是永久阻塞在 v.Recv() 或向 out 发送上。
+func TestAsReceivableChanStopsForwardingOnContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	src := make(chan interface{})
+
+	out, ok := asReceivableChan(ctx, src)
+	if !ok {
+		t.Fatal("expected a bidirectional chan interface{} to be recognized")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Fatal("expected out to be closed once the forwarding goroutine exits")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("forwarding goroutine did not exit (close out) after context cancellation")
+	}
+}